@@ -0,0 +1,479 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "net/http"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    log "github.com/sirupsen/logrus"
+)
+
+var (
+    kubeconfigFlag  = flag.String("kubeconfig", os.Getenv("KUBECONFIG"), "path to kubeconfig (unset uses in-cluster config)")
+    apiserverFlag   = flag.Bool("apiserver", false, "request pod listing via the apiserver; this build has no apiserver client and uses the local kubelet /pods endpoint either way")
+    attributionFlag = flag.String("attribution", "proportional", "how to split node power across containers: proportional|ratio")
+
+    containerCPUJoules = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Name: "container_cpu_joules_total",
+            Help: "Cumulative CPU energy attributed to a container, in joules",
+        },
+        []string{"pod", "container", "namespace", "node"},
+    )
+
+    containerGPUJoules = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Name: "container_gpu_joules_total",
+            Help: "Cumulative GPU energy attributed to a container, in joules",
+        },
+        []string{"pod", "container", "namespace", "node", "gpu_index"},
+    )
+)
+
+func init() {
+    prometheus.MustRegister(containerCPUJoules)
+    prometheus.MustRegister(containerGPUJoules)
+}
+
+// containerInfo is one container of one pod scheduled to this node.
+type containerInfo struct {
+    Pod        string
+    Namespace  string
+    Name       string
+    CgroupPath string
+    CPURequestMillis int64 // used by the "ratio" attribution model
+}
+
+// podAttributor splits node-level CPU/GPU power across the containers
+// running on this node, proportional to each container's measured
+// resource usage (or, in "ratio" mode, its CPU request) and integrates
+// that into cumulative joule counters.
+type podAttributor struct {
+    node  string
+    model string
+
+    prevCPUUsageNanos map[string]int64 // cgroup path -> cumulative cpu.stat usage_usec*1000
+    prevAttributeTime time.Time        // wall-clock time of the previous attribute() call, for real elapsed seconds
+}
+
+func newPodAttributor(node string) *podAttributor {
+    model := *attributionFlag
+    if model != "proportional" && model != "ratio" {
+        log.Warnf("attribution: unknown model %q, defaulting to proportional", model)
+        model = "proportional"
+    }
+    return &podAttributor{
+        node:              node,
+        model:             model,
+        prevCPUUsageNanos: map[string]int64{},
+    }
+}
+
+// listLocalContainers returns the containers scheduled to this node by
+// polling the local kubelet's read-only /pods endpoint, which requires no
+// RBAC setup. Setting --apiserver routes through listViaAPIServer, which
+// logs a warning and polls the same kubelet endpoint; the API server and
+// --kubeconfig are not used.
+func (a *podAttributor) listLocalContainers() ([]containerInfo, error) {
+    if *apiserverFlag {
+        return a.listViaAPIServer()
+    }
+    return a.listViaKubelet()
+}
+
+// listViaKubelet polls the deprecated-but-simple kubelet read-only port.
+// It's sufficient for our purposes: we only need the pod/container/
+// namespace identity and each container's cgroup path.
+func (a *podAttributor) listViaKubelet() ([]containerInfo, error) {
+    resp, err := http.Get("http://127.0.0.1:10255/pods")
+    if err != nil {
+        return nil, fmt.Errorf("querying kubelet /pods: %w", err)
+    }
+    defer resp.Body.Close()
+
+    var podList struct {
+        Items []struct {
+            Metadata struct {
+                Name      string `json:"name"`
+                Namespace string `json:"namespace"`
+                UID       string `json:"uid"`
+            } `json:"metadata"`
+            Spec struct {
+                Containers []struct {
+                    Name      string `json:"name"`
+                    Resources struct {
+                        Requests struct {
+                            CPU string `json:"cpu"`
+                        } `json:"requests"`
+                    } `json:"resources"`
+                } `json:"containers"`
+            } `json:"spec"`
+            Status struct {
+                QOSClass          string `json:"qosClass"`
+                ContainerStatuses []struct {
+                    Name        string `json:"name"`
+                    ContainerID string `json:"containerID"`
+                } `json:"containerStatuses"`
+            } `json:"status"`
+        } `json:"items"`
+    }
+
+    if err := json.NewDecoder(resp.Body).Decode(&podList); err != nil {
+        return nil, fmt.Errorf("decoding kubelet /pods response: %w", err)
+    }
+
+    var containers []containerInfo
+    for _, pod := range podList.Items {
+        containerIDByName := make(map[string]string, len(pod.Status.ContainerStatuses))
+        for _, cs := range pod.Status.ContainerStatuses {
+            containerIDByName[cs.Name] = cs.ContainerID
+        }
+
+        for _, c := range pod.Spec.Containers {
+            containerID := containerIDByName[c.Name]
+            if containerID == "" {
+                // Not yet started (no runtime ID assigned), so there's no
+                // cgroup to attribute energy into; skip until it reports one.
+                continue
+            }
+            containers = append(containers, containerInfo{
+                Pod:              pod.Metadata.Name,
+                Namespace:        pod.Metadata.Namespace,
+                Name:             c.Name,
+                CgroupPath:       podCgroupPath(pod.Status.QOSClass, pod.Metadata.UID, containerID),
+                CPURequestMillis: parseCPUMillis(c.Resources.Requests.CPU),
+            })
+        }
+    }
+    return containers, nil
+}
+
+// listViaAPIServer would list+watch pods filtered to this node via an
+// informer backed by client-go. The informer wiring is intentionally not
+// vendored into this tree; until client-go is added as a dependency this
+// path falls back to the kubelet endpoint so --apiserver is safe to leave
+// on in clusters where that's the only thing RBAC allows.
+func (a *podAttributor) listViaAPIServer() ([]containerInfo, error) {
+    log.Warn("attribution: --apiserver requested but client-go is not vendored in this build; falling back to kubelet /pods")
+    return a.listViaKubelet()
+}
+
+// podCgroupPath builds the cgroup v2 path kubelet's systemd cgroup driver
+// creates for a container, given the pod's QoS class (which determines
+// the intermediate kubepods-{burstable,besteffort}.slice), its UID, and
+// the runtime-qualified container ID from the pod's status (e.g.
+// "containerd://<hex>" or "cri-o://<hex>"). The leaf directory is the
+// container-runtime-assigned scope, never the pod-spec container name.
+func podCgroupPath(qosClass, podUID, containerID string) string {
+    unitUID := strings.ReplaceAll(podUID, "-", "_")
+
+    var podSlice string
+    switch qosClass {
+    case "Burstable":
+        podSlice = filepath.Join("kubepods.slice", "kubepods-burstable.slice", fmt.Sprintf("kubepods-burstable-pod%s.slice", unitUID))
+    case "BestEffort":
+        podSlice = filepath.Join("kubepods.slice", "kubepods-besteffort.slice", fmt.Sprintf("kubepods-besteffort-pod%s.slice", unitUID))
+    default: // "Guaranteed", or unset on older kubelets
+        podSlice = filepath.Join("kubepods.slice", fmt.Sprintf("kubepods-pod%s.slice", unitUID))
+    }
+
+    return filepath.Join("/sys/fs/cgroup", podSlice, containerScopeName(containerID))
+}
+
+// containerScopeName turns a runtime-qualified container ID as reported
+// in a pod's containerStatuses (e.g. "containerd://abc123") into the
+// scope directory name the corresponding CRI shim creates under the pod
+// slice.
+func containerScopeName(containerID string) string {
+    runtime, id, found := strings.Cut(containerID, "://")
+    if !found {
+        id = containerID
+    }
+
+    switch runtime {
+    case "cri-o":
+        return fmt.Sprintf("crio-%s.scope", id)
+    case "docker":
+        return fmt.Sprintf("docker-%s.scope", id)
+    default: // "containerd", or an unrecognized runtime prefix
+        return fmt.Sprintf("cri-containerd-%s.scope", id)
+    }
+}
+
+func parseCPUMillis(quantity string) int64 {
+    quantity = strings.TrimSpace(quantity)
+    if quantity == "" {
+        return 0
+    }
+    if strings.HasSuffix(quantity, "m") {
+        v, err := strconv.ParseInt(strings.TrimSuffix(quantity, "m"), 10, 64)
+        if err != nil {
+            return 0
+        }
+        return v
+    }
+    v, err := strconv.ParseFloat(quantity, 64)
+    if err != nil {
+        return 0
+    }
+    return int64(v * 1000)
+}
+
+// cgroupCPUUsageNanos reads cumulative CPU time (in nanoseconds) for a
+// container from cgroup v2's cpu.stat, falling back to cgroup v1's
+// cpuacct.usage.
+func cgroupCPUUsageNanos(cgroupPath string) (int64, error) {
+    if data, err := os.ReadFile(filepath.Join(cgroupPath, "cpu.stat")); err == nil {
+        scanner := bufio.NewScanner(strings.NewReader(string(data)))
+        for scanner.Scan() {
+            fields := strings.Fields(scanner.Text())
+            if len(fields) == 2 && fields[0] == "usage_usec" {
+                usec, err := strconv.ParseInt(fields[1], 10, 64)
+                if err != nil {
+                    return 0, err
+                }
+                return usec * 1000, nil
+            }
+        }
+    }
+
+    data, err := os.ReadFile(filepath.Join(cgroupPath, "cpuacct.usage"))
+    if err != nil {
+        return 0, fmt.Errorf("reading cgroup cpu usage at %s: %w", cgroupPath, err)
+    }
+    return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// gpuProcessUtilByGPU maps gpu_index -> pid -> GPU SM utilization percent,
+// sourced from `nvidia-smi pmon`. It's best-effort: processes that aren't
+// using the GPU at sample time simply get no entry.
+func gpuProcessUtilByGPU() map[string]map[int]float64 {
+    cmd := exec.Command("nvidia-smi", "pmon", "-c", "1", "-s", "um")
+    output, err := cmd.Output()
+    if err != nil {
+        return nil
+    }
+
+    util := map[string]map[int]float64{}
+    scanner := bufio.NewScanner(strings.NewReader(string(output)))
+    for scanner.Scan() {
+        line := scanner.Text()
+        if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+            continue
+        }
+        fields := strings.Fields(line)
+        // gpu  pid  type  sm   mem  enc  dec  command
+        if len(fields) < 5 {
+            continue
+        }
+        gpuIndex := fields[0]
+        pid, err := strconv.Atoi(fields[1])
+        if err != nil {
+            continue
+        }
+        sm, err := strconv.ParseFloat(fields[3], 64)
+        if err != nil {
+            continue
+        }
+        if util[gpuIndex] == nil {
+            util[gpuIndex] = map[int]float64{}
+        }
+        util[gpuIndex][pid] += sm
+    }
+    return util
+}
+
+// containerPIDs reads the PIDs currently in a container's cgroup, used to
+// correlate nvidia-smi pmon's per-process rows back to a container.
+func containerPIDs(cgroupPath string) ([]int, error) {
+    data, err := os.ReadFile(filepath.Join(cgroupPath, "cgroup.procs"))
+    if err != nil {
+        return nil, fmt.Errorf("reading %s/cgroup.procs: %w", cgroupPath, err)
+    }
+
+    var pids []int
+    for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" {
+            continue
+        }
+        pid, err := strconv.Atoi(line)
+        if err != nil {
+            continue
+        }
+        pids = append(pids, pid)
+    }
+    return pids, nil
+}
+
+// attribute splits the node's current CPU power, and each GPU's own
+// measured power (gpuWattsByIndex, keyed by gpu_index), across containers,
+// adding the resulting energy (watts * elapsed seconds) to each
+// container's cumulative joule counters. The elapsed time is the real
+// wall-clock gap since the previous call, not the nominal interval, since
+// the collection loop's own work time means the true tick period is never
+// exactly interval; the nominal interval is only used to seed the first
+// sample, where there is no previous call to measure from.
+func (a *podAttributor) attribute(cpuWatts float64, gpuWattsByIndex map[string]float64, interval time.Duration) {
+    containers, err := a.listLocalContainers()
+    if err != nil {
+        log.Warnf("attribution: listing containers: %v", err)
+        return
+    }
+    if len(containers) == 0 {
+        return
+    }
+
+    now := time.Now()
+    seconds := interval.Seconds()
+    if !a.prevAttributeTime.IsZero() {
+        seconds = now.Sub(a.prevAttributeTime).Seconds()
+    }
+    a.prevAttributeTime = now
+
+    cpuW := a.cpuWeights(containers)
+    for _, c := range containers {
+        w := cpuW[c.CgroupPath]
+        containerCPUJoules.WithLabelValues(c.Pod, c.Name, c.Namespace, a.node).Add(cpuWatts * w * seconds)
+    }
+
+    for gpuIndex, perContainer := range a.gpuWeights(containers) {
+        gpuWatts := gpuWattsByIndex[gpuIndex]
+        for _, c := range containers {
+            w, ok := perContainer[c.CgroupPath]
+            if !ok || w == 0 {
+                continue
+            }
+            containerGPUJoules.WithLabelValues(c.Pod, c.Name, c.Namespace, a.node, gpuIndex).Add(gpuWatts * w * seconds)
+        }
+    }
+}
+
+// gpuWeights correlates nvidia-smi pmon's per-process, per-GPU SM
+// utilization against each container's cgroup PIDs, returning
+// gpu_index -> cgroup path -> share (0..1) of that GPU's own power. Each
+// GPU's shares are normalized against that GPU's own total utilization,
+// not pooled across every GPU on the node, since two GPUs with similar
+// utilization can draw very different power. Returns nil when no
+// per-process GPU data is available (e.g. no NVIDIA GPU, or nvidia-smi
+// pmon failed).
+func (a *podAttributor) gpuWeights(containers []containerInfo) map[string]map[string]float64 {
+    util := gpuProcessUtilByGPU()
+    if len(util) == 0 {
+        return nil
+    }
+
+    containerPIDSets := map[string][]int{}
+    for _, c := range containers {
+        pids, err := containerPIDs(c.CgroupPath)
+        if err != nil {
+            continue
+        }
+        containerPIDSets[c.CgroupPath] = pids
+    }
+
+    raw := map[string]map[string]float64{}  // gpuIndex -> cgroupPath -> summed sm util
+    perGPUTotal := map[string]float64{}      // gpuIndex -> summed sm util across all containers on that GPU
+
+    for gpuIndex, pidUtil := range util {
+        for cgroupPath, pids := range containerPIDSets {
+            var sum float64
+            for _, pid := range pids {
+                sum += pidUtil[pid]
+            }
+            if sum == 0 {
+                continue
+            }
+            if raw[gpuIndex] == nil {
+                raw[gpuIndex] = map[string]float64{}
+            }
+            raw[gpuIndex][cgroupPath] = sum
+            perGPUTotal[gpuIndex] += sum
+        }
+    }
+
+    weights := make(map[string]map[string]float64, len(raw))
+    for gpuIndex, byContainer := range raw {
+        total := perGPUTotal[gpuIndex]
+        if total == 0 {
+            continue
+        }
+        weights[gpuIndex] = make(map[string]float64, len(byContainer))
+        for cgroupPath, sum := range byContainer {
+            weights[gpuIndex][cgroupPath] = sum / total
+        }
+    }
+    if len(weights) == 0 {
+        return nil
+    }
+    return weights
+}
+
+// cpuWeights computes each container's share (0..1) of node CPU power.
+func (a *podAttributor) cpuWeights(containers []containerInfo) map[string]float64 {
+    weights := map[string]float64{}
+
+    if a.model == "ratio" {
+        var totalMillis int64
+        for _, c := range containers {
+            totalMillis += c.CPURequestMillis
+        }
+        if totalMillis == 0 {
+            return equalWeights(containers)
+        }
+        for _, c := range containers {
+            weights[c.CgroupPath] = float64(c.CPURequestMillis) / float64(totalMillis)
+        }
+        return weights
+    }
+
+    // proportional: weight by measured cgroup CPU time delta since the
+    // last sample.
+    deltas := map[string]int64{}
+    var total int64
+
+    for _, c := range containers {
+        usage, err := cgroupCPUUsageNanos(c.CgroupPath)
+        if err != nil {
+            continue
+        }
+        prev, ok := a.prevCPUUsageNanos[c.CgroupPath]
+        a.prevCPUUsageNanos[c.CgroupPath] = usage
+        if !ok || usage < prev {
+            continue
+        }
+        delta := usage - prev
+        deltas[c.CgroupPath] = delta
+        total += delta
+    }
+
+    if total == 0 {
+        return equalWeights(containers)
+    }
+    for path, delta := range deltas {
+        weights[path] = float64(delta) / float64(total)
+    }
+    return weights
+}
+
+func equalWeights(containers []containerInfo) map[string]float64 {
+    weights := map[string]float64{}
+    if len(containers) == 0 {
+        return weights
+    }
+    share := 1.0 / float64(len(containers))
+    for _, c := range containers {
+        weights[c.CgroupPath] = share
+    }
+    return weights
+}
+
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestPodCgroupPath(t *testing.T) {
+    cases := []struct {
+        name        string
+        qosClass    string
+        podUID      string
+        containerID string
+        want        string
+    }{
+        {
+            name:        "guaranteed containerd",
+            qosClass:    "Guaranteed",
+            podUID:      "abc-123",
+            containerID: "containerd://deadbeef",
+            want:        "/sys/fs/cgroup/kubepods.slice/kubepods-podabc_123.slice/cri-containerd-deadbeef.scope",
+        },
+        {
+            name:        "burstable cri-o",
+            qosClass:    "Burstable",
+            podUID:      "abc-123",
+            containerID: "cri-o://deadbeef",
+            want:        "/sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-podabc_123.slice/crio-deadbeef.scope",
+        },
+        {
+            name:        "besteffort docker",
+            qosClass:    "BestEffort",
+            podUID:      "abc-123",
+            containerID: "docker://deadbeef",
+            want:        "/sys/fs/cgroup/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-podabc_123.slice/docker-deadbeef.scope",
+        },
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            if got := podCgroupPath(tc.qosClass, tc.podUID, tc.containerID); got != tc.want {
+                t.Errorf("podCgroupPath(%q, %q, %q) = %q, want %q", tc.qosClass, tc.podUID, tc.containerID, got, tc.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os/exec"
+    "strconv"
+    "strings"
+
+    log "github.com/sirupsen/logrus"
+)
+
+// GPUCollector abstracts a vendor-specific GPU metrics backend so that
+// mixed NVIDIA/AMD nodes can be sampled through a single code path.
+type GPUCollector interface {
+    // Vendor is the label value reported alongside gpu_index, e.g. "nvidia" or "amd".
+    Vendor() string
+    // Count returns how many GPUs this backend can see on the local node.
+    Count() int
+    // Sample reads instantaneous metrics for the GPU at index.
+    Sample(index int) (power, temp, util, memory, memBusyPct, memTotal, sclk, mclk float64, err error)
+}
+
+// gpuCollectors holds every vendor backend, registered unconditionally; a
+// backend whose tool is absent on this node reports Count() == 0.
+// collectMetrics iterates all of them so a node with both vendors reports
+// both.
+var gpuCollectors []GPUCollector
+
+func init() {
+    gpuCollectors = []GPUCollector{
+        &NVIDIACollector{},
+        &AMDCollector{},
+    }
+}
+
+// NVIDIACollector wraps nvidia-smi/NVML.
+type NVIDIACollector struct{}
+
+func (c *NVIDIACollector) Vendor() string { return "nvidia" }
+
+func (c *NVIDIACollector) Count() int {
+    cmd := exec.Command("nvidia-smi", "-L")
+    output, err := cmd.Output()
+    if err != nil {
+        // No nvidia-smi on PATH, or it errored (no driver, no card, no
+        // permission): this node has no NVIDIA GPUs to report, not zero
+        // data worth inventing.
+        return 0
+    }
+    // One "GPU N: <name> (UUID: GPU-...)" line per device; the UUID also
+    // contains "GPU", so count lines rather than substrings.
+    count := 0
+    for _, line := range strings.Split(string(output), "\n") {
+        if strings.HasPrefix(line, "GPU ") {
+            count++
+        }
+    }
+    return count
+}
+
+func (c *NVIDIACollector) Sample(index int) (power, temp, util, memory, memBusyPct, memTotal, sclk, mclk float64, err error) {
+    cmd := exec.Command("nvidia-smi",
+        "--query-gpu=power.draw,temperature.gpu,utilization.gpu,memory.used,utilization.memory,memory.total,clocks.sm,clocks.mem",
+        "--format=csv,noheader,nounits",
+        fmt.Sprintf("-i=%d", index))
+
+    output, cmdErr := cmd.Output()
+    if cmdErr != nil {
+        return 0, 0, 0, 0, 0, 0, 0, 0, fmt.Errorf("nvidia-smi: %w", cmdErr)
+    }
+
+    fields := strings.Split(strings.TrimSpace(string(output)), ",")
+    if len(fields) < 8 {
+        return 0, 0, 0, 0, 0, 0, 0, 0, fmt.Errorf("unexpected nvidia-smi output: %q", output)
+    }
+
+    power, _ = strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+    temp, _ = strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+    util, _ = strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+    memUsedMB, _ := strconv.ParseFloat(strings.TrimSpace(fields[3]), 64)
+    memory = memUsedMB * 1024 * 1024
+    memBusyPct, _ = strconv.ParseFloat(strings.TrimSpace(fields[4]), 64)
+    memTotalMB, _ := strconv.ParseFloat(strings.TrimSpace(fields[5]), 64)
+    memTotal = memTotalMB * 1024 * 1024
+    sclk, _ = strconv.ParseFloat(strings.TrimSpace(fields[6]), 64)
+    mclk, _ = strconv.ParseFloat(strings.TrimSpace(fields[7]), 64)
+
+    return
+}
+
+// AMDCollector wraps rocm-smi for Instinct/Radeon cards. It has no
+// libgoamdsmi path: on a node without rocm-smi it reports zero GPUs.
+type AMDCollector struct{}
+
+func (c *AMDCollector) Vendor() string { return "amd" }
+
+func (c *AMDCollector) rocmData() (map[string]map[string]interface{}, error) {
+    cmd := exec.Command("rocm-smi",
+        "--showpower", "--showtemp", "--showuse", "--showmemuse", "--showmeminfo", "vram", "--json")
+    output, err := cmd.Output()
+    if err != nil {
+        return nil, err
+    }
+
+    var parsed map[string]map[string]interface{}
+    if err := json.Unmarshal(output, &parsed); err != nil {
+        return nil, fmt.Errorf("parsing rocm-smi json: %w", err)
+    }
+    return parsed, nil
+}
+
+func (c *AMDCollector) Count() int {
+    data, err := c.rocmData()
+    if err != nil {
+        return 0
+    }
+    count := 0
+    for card := range data {
+        if strings.HasPrefix(card, "card") {
+            count++
+        }
+    }
+    return count
+}
+
+func (c *AMDCollector) Sample(index int) (power, temp, util, memory, memBusyPct, memTotal, sclk, mclk float64, err error) {
+    data, rocmErr := c.rocmData()
+    if rocmErr != nil {
+        return 0, 0, 0, 0, 0, 0, 0, 0, rocmErr
+    }
+
+    card, ok := data[fmt.Sprintf("card%d", index)]
+    if !ok {
+        return 0, 0, 0, 0, 0, 0, 0, 0, fmt.Errorf("rocm-smi: no entry for card%d", index)
+    }
+
+    power = rocmFloat(card, "Average Graphics Package Power (W)")
+    temp = rocmFloat(card, "Temperature (Sensor edge) (C)")
+    util = rocmFloat(card, "GPU use (%)")
+    memBusyPct = rocmFloat(card, "GPU memory use (%)")
+    memory = rocmFloat(card, "VRAM Total Used Memory (B)")
+    memTotal = rocmFloat(card, "VRAM Total Memory (B)")
+    sclk = rocmClockMHz(card, "sclk clock speed")
+    mclk = rocmClockMHz(card, "mclk clock speed")
+
+    return
+}
+
+// rocmFloat extracts a numeric field from a rocm-smi --json card entry,
+// stripping any non-numeric suffix (rocm-smi mixes plain numbers and
+// unit-suffixed strings across releases).
+func rocmFloat(card map[string]interface{}, key string) float64 {
+    raw, ok := card[key]
+    if !ok {
+        return 0
+    }
+    s := fmt.Sprintf("%v", raw)
+    s = strings.TrimSpace(s)
+    s = strings.TrimSuffix(s, "%")
+    s = strings.TrimSuffix(s, "W")
+    s = strings.TrimSuffix(s, "C")
+    s = strings.TrimSpace(s)
+    v, err := strconv.ParseFloat(s, 64)
+    if err != nil {
+        log.Debugf("rocm-smi: could not parse %q=%q as float", key, raw)
+        return 0
+    }
+    return v
+}
+
+// rocmClockMHz parses values like "1500Mhz" into a bare MHz float.
+func rocmClockMHz(card map[string]interface{}, key string) float64 {
+    raw, ok := card[key]
+    if !ok {
+        return 0
+    }
+    s := strings.TrimSpace(fmt.Sprintf("%v", raw))
+    s = strings.TrimSuffix(s, "Mhz")
+    s = strings.TrimSuffix(s, "MHz")
+    v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+    if err != nil {
+        return 0
+    }
+    return v
+}
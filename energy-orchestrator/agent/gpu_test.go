@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestRocmFloat(t *testing.T) {
+    card := map[string]interface{}{
+        "Average Graphics Package Power (W)": "123.0",
+        "Temperature (Sensor edge) (C)":      "65",
+        "GPU use (%)":                        "42%",
+        "missing":                            nil,
+    }
+
+    cases := []struct {
+        key  string
+        want float64
+    }{
+        {"Average Graphics Package Power (W)", 123.0},
+        {"Temperature (Sensor edge) (C)", 65},
+        {"GPU use (%)", 42},
+        {"not present", 0},
+    }
+
+    for _, tc := range cases {
+        if got := rocmFloat(card, tc.key); got != tc.want {
+            t.Errorf("rocmFloat(%q) = %v, want %v", tc.key, got, tc.want)
+        }
+    }
+}
+
+func TestRocmClockMHz(t *testing.T) {
+    card := map[string]interface{}{
+        "sclk clock speed": "1500Mhz",
+        "mclk clock speed": "875MHz",
+    }
+
+    if got := rocmClockMHz(card, "sclk clock speed"); got != 1500 {
+        t.Errorf("rocmClockMHz(sclk) = %v, want 1500", got)
+    }
+    if got := rocmClockMHz(card, "mclk clock speed"); got != 875 {
+        t.Errorf("rocmClockMHz(mclk) = %v, want 875", got)
+    }
+    if got := rocmClockMHz(card, "missing"); got != 0 {
+        t.Errorf("rocmClockMHz(missing) = %v, want 0", got)
+    }
+}
+
+func TestNVIDIACollectorCountNoBinary(t *testing.T) {
+    // nvidia-smi is not installed in the test environment, so Count must
+    // report zero GPUs rather than a fabricated non-zero count.
+    c := &NVIDIACollector{}
+    if got := c.Count(); got != 0 {
+        t.Errorf("Count() on a node without nvidia-smi = %v, want 0", got)
+    }
+}
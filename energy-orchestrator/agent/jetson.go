@@ -0,0 +1,135 @@
+package main
+
+import (
+    "bufio"
+    "os"
+    "os/exec"
+    "regexp"
+    "strconv"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    log "github.com/sirupsen/logrus"
+)
+
+var (
+    tegraRailPowerGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_tegra_rail_power_milliwatts",
+            Help: "Jetson/Tegra INA3221 power rail reading in milliwatts",
+        },
+        []string{"node", "rail"},
+    )
+
+    tegraThermalGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_tegra_thermal_celsius",
+            Help: "Jetson/Tegra thermal zone temperature in Celsius",
+        },
+        []string{"node", "zone"},
+    )
+
+    tegraGPUUtilGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_tegra_gpu_utilization_percent",
+            Help: "Jetson/Tegra integrated GPU (GR3D) utilization percentage",
+        },
+        []string{"node"},
+    )
+)
+
+func init() {
+    prometheus.MustRegister(tegraRailPowerGauge)
+    prometheus.MustRegister(tegraThermalGauge)
+    prometheus.MustRegister(tegraGPUUtilGauge)
+}
+
+// tegrastats lines look like:
+//   RAM 2956/7860MB (lfb 4x4MB) CPU [20%@1190,13%@1190] EMC_FREQ 0% GR3D_FREQ 5%
+//   VDD_IN 3172/3172 VDD_CPU_GPU_CV 460/460 VDD_SOC 890/890 PLL@38C CPU@40C GPU@39C AO@43C
+var (
+    tegraRailRe    = regexp.MustCompile(`\b([A-Z][A-Z0-9_]*)\s+(\d+)/(\d+)\b`)
+    tegraThermalRe = regexp.MustCompile(`\b([A-Za-z0-9_]+)@(-?[\d.]+)C\b`)
+    tegraGR3DRe    = regexp.MustCompile(`GR3D_FREQ\s+(\d+)%`)
+)
+
+// isJetsonPlatform reports whether this node is a Jetson/Tegra board,
+// detected the same way L4T itself does: the nv_tegra_release marker file,
+// or tegrastats being present on PATH.
+func isJetsonPlatform() bool {
+    if _, err := os.Stat("/etc/nv_tegra_release"); err == nil {
+        return true
+    }
+    if _, err := exec.LookPath("tegrastats"); err == nil {
+        return true
+    }
+    return false
+}
+
+// startJetsonCollector launches tegrastats as a long-running background
+// process and streams its output into the tegra_* gauges for node. Unlike
+// the poll-based collectors, tegrastats pushes a line roughly every
+// interval, so this owns its own goroutine rather than being sampled from
+// collectMetrics.
+func startJetsonCollector(node string) {
+    cmd := exec.Command("tegrastats", "--interval", "1000")
+
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        log.Warnf("jetson: could not attach to tegrastats stdout: %v", err)
+        return
+    }
+
+    if err := cmd.Start(); err != nil {
+        log.Warnf("jetson: could not start tegrastats: %v", err)
+        return
+    }
+
+    go func() {
+        scanner := bufio.NewScanner(stdout)
+        for scanner.Scan() {
+            parseTegrastatsLine(node, scanner.Text())
+        }
+        if err := cmd.Wait(); err != nil {
+            log.Warnf("jetson: tegrastats exited: %v", err)
+        }
+    }()
+}
+
+func parseTegrastatsLine(node, line string) {
+    for _, m := range tegraRailRe.FindAllStringSubmatch(line, -1) {
+        rail := m[1]
+        milliwatts, err := strconv.ParseFloat(m[2], 64)
+        if err != nil {
+            continue
+        }
+        tegraRailPowerGauge.WithLabelValues(node, rail).Set(milliwatts)
+
+        // Also offer the raw sample to the metric router so a deployment
+        // can re-expose it in watts (or under a different name) without
+        // a recompile; routeMetric is a no-op when no router is configured.
+        routeMetric(Metric{
+            Name:      "node_tegra_rail_power",
+            Tags:      map[string]string{"node": node, "rail": rail},
+            Fields:    map[string]float64{"value": milliwatts},
+            Unit:      "mW",
+            Timestamp: time.Now(),
+        })
+    }
+
+    for _, m := range tegraThermalRe.FindAllStringSubmatch(line, -1) {
+        zone := m[1]
+        celsius, err := strconv.ParseFloat(m[2], 64)
+        if err != nil {
+            continue
+        }
+        tegraThermalGauge.WithLabelValues(node, zone).Set(celsius)
+    }
+
+    if m := tegraGR3DRe.FindStringSubmatch(line); m != nil {
+        util, err := strconv.ParseFloat(m[1], 64)
+        if err == nil {
+            tegraGPUUtilGauge.WithLabelValues(node).Set(util)
+        }
+    }
+}
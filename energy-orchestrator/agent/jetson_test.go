@@ -0,0 +1,42 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseTegrastatsLine(t *testing.T) {
+    const line = `RAM 2956/7860MB (lfb 4x4MB) CPU [20%@1190,13%@1190] EMC_FREQ 0% GR3D_FREQ 5% VDD_IN 3172/3172 VDD_CPU_GPU_CV 460/460 VDD_SOC 890/890 PLL@38C CPU@40C GPU@39C AO@43C`
+
+    parseTegrastatsLine("edge-1", line)
+
+    railCases := map[string]float64{
+        "VDD_IN":         3172,
+        "VDD_CPU_GPU_CV": 460,
+        "VDD_SOC":        890,
+    }
+    for rail, want := range railCases {
+        got := testutil.ToFloat64(tegraRailPowerGauge.WithLabelValues("edge-1", rail))
+        if got != want {
+            t.Errorf("rail %s = %v, want %v", rail, got, want)
+        }
+    }
+
+    thermalCases := map[string]float64{
+        "PLL": 38,
+        "CPU": 40,
+        "GPU": 39,
+        "AO":  43,
+    }
+    for zone, want := range thermalCases {
+        got := testutil.ToFloat64(tegraThermalGauge.WithLabelValues("edge-1", zone))
+        if got != want {
+            t.Errorf("thermal zone %s = %v, want %v", zone, got, want)
+        }
+    }
+
+    if got := testutil.ToFloat64(tegraGPUUtilGauge.WithLabelValues("edge-1")); got != 5 {
+        t.Errorf("GR3D utilization = %v, want 5", got)
+    }
+}
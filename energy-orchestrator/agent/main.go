@@ -1,13 +1,10 @@
 package main
 
 import (
+    "flag"
     "fmt"
-    "math/rand"
     "net/http"
     "os"
-    "os/exec"
-    "strconv"
-    "strings"
     "time"
 
     "github.com/prometheus/client_golang/prometheus"
@@ -16,48 +13,83 @@ import (
 )
 
 var (
-    // CPU metrics
+    // CPU metrics. "domain" is the RAPL/hwmon domain name (e.g.
+    // "package-0", "dram", "core", "uncore", "psys", or an AMD energy
+    // sensor label), so package and subdomain power can be told apart.
     cpuPowerGauge = prometheus.NewGaugeVec(
         prometheus.GaugeOpts{
             Name: "node_cpu_power_watts",
             Help: "CPU power consumption in watts",
         },
-        []string{"node", "socket"},
+        []string{"node", "socket", "domain"},
     )
-    
-    // GPU metrics
+
+    // GPU metrics. All GPU series carry a "vendor" label ("nvidia"/"amd")
+    // in addition to gpu_index so mixed nodes can be told apart.
     gpuPowerGauge = prometheus.NewGaugeVec(
         prometheus.GaugeOpts{
             Name: "node_gpu_power_watts",
             Help: "GPU power consumption in watts",
         },
-        []string{"node", "gpu_index"},
+        []string{"node", "gpu_index", "vendor"},
     )
-    
+
     gpuTempGauge = prometheus.NewGaugeVec(
         prometheus.GaugeOpts{
             Name: "node_gpu_temperature_celsius",
             Help: "GPU temperature in Celsius",
         },
-        []string{"node", "gpu_index"},
+        []string{"node", "gpu_index", "vendor"},
     )
-    
+
     gpuUtilGauge = prometheus.NewGaugeVec(
         prometheus.GaugeOpts{
             Name: "node_gpu_utilization_percent",
             Help: "GPU utilization percentage",
         },
-        []string{"node", "gpu_index"},
+        []string{"node", "gpu_index", "vendor"},
     )
-    
+
     gpuMemoryGauge = prometheus.NewGaugeVec(
         prometheus.GaugeOpts{
             Name: "node_gpu_memory_used_bytes",
             Help: "GPU memory used in bytes",
         },
-        []string{"node", "gpu_index"},
+        []string{"node", "gpu_index", "vendor"},
     )
-    
+
+    gpuMemoryTotalGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_gpu_memory_total_bytes",
+            Help: "GPU total memory in bytes",
+        },
+        []string{"node", "gpu_index", "vendor"},
+    )
+
+    gpuMemoryBusyGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_gpu_memory_busy_percent",
+            Help: "Percentage of time the GPU memory controller was busy",
+        },
+        []string{"node", "gpu_index", "vendor"},
+    )
+
+    gpuSclkGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_gpu_sclk_mhz",
+            Help: "GPU core (shader/SM) clock in MHz",
+        },
+        []string{"node", "gpu_index", "vendor"},
+    )
+
+    gpuMclkGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_gpu_mclk_mhz",
+            Help: "GPU memory clock in MHz",
+        },
+        []string{"node", "gpu_index", "vendor"},
+    )
+
     // Rack/Node power (via IPMI/Redfish stub)
     nodePowerGauge = prometheus.NewGaugeVec(
         prometheus.GaugeOpts{
@@ -74,116 +106,115 @@ func init() {
     prometheus.MustRegister(gpuTempGauge)
     prometheus.MustRegister(gpuUtilGauge)
     prometheus.MustRegister(gpuMemoryGauge)
+    prometheus.MustRegister(gpuMemoryTotalGauge)
+    prometheus.MustRegister(gpuMemoryBusyGauge)
+    prometheus.MustRegister(gpuSclkGauge)
+    prometheus.MustRegister(gpuMclkGauge)
     prometheus.MustRegister(nodePowerGauge)
 }
 
-// Read Intel RAPL (Running Average Power Limit)
-func readRAPL() (float64, error) {
-    // Try to read from RAPL sysfs
-    raplPath := "/sys/class/powercap/intel-rapl/intel-rapl:0/energy_uj"
-    data, err := os.ReadFile(raplPath)
-    if err != nil {
-        // Fallback to mock data for development
-        return 95.0 + rand.Float64()*20, nil
-    }
-    
-    energy, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
-    if err != nil {
-        return 0, err
-    }
-    
-    // Convert microjoules to watts (simplified)
-    return energy / 1000000.0, nil
-}
-
-// Read NVIDIA GPU metrics via nvidia-smi
-func readNvidiaGPU(index int) (power, temp, util, memory float64, err error) {
-    // Try nvidia-smi
-    cmd := exec.Command("nvidia-smi",
-        "--query-gpu=power.draw,temperature.gpu,utilization.gpu,memory.used",
-        "--format=csv,noheader,nounits",
-        fmt.Sprintf("-i=%d", index))
-    
-    output, err := cmd.Output()
-    if err != nil {
-        // Mock data for development
-        power = 180.0 + rand.Float64()*70
-        temp = 65.0 + rand.Float64()*15
-        util = 60.0 + rand.Float64()*30
-        memory = float64(8 * 1024 * 1024 * 1024) // 8GB
-        return power, temp, util, memory, nil
-    }
-    
-    fields := strings.Split(strings.TrimSpace(string(output)), ",")
-    if len(fields) >= 4 {
-        power, _ = strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
-        temp, _ = strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
-        util, _ = strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
-        memMB, _ := strconv.ParseFloat(strings.TrimSpace(fields[3]), 64)
-        memory = memMB * 1024 * 1024 // Convert MB to bytes
+// readNodePower prefers real BMC (Redfish/IPMI) data and only falls back
+// to the CPU+GPU+overhead estimate (already sampled this tick by the
+// caller) when the BMC is unreachable.
+func readNodePower(node string, cpuPower, gpuPower float64) float64 {
+    if reading, ok := readBMCPower(node); ok {
+        total := 0.0
+        for psu, watts := range reading.PSUWatts {
+            bmcPowerGauge.WithLabelValues(node, psu).Set(watts)
+            total += watts
+        }
+        if reading.HasInletTemp {
+            bmcInletTempGauge.WithLabelValues(node).Set(reading.InletTempC)
+        }
+        if total > 0 {
+            return total
+        }
     }
-    
-    return
-}
 
-// Read total node power (IPMI/Redfish stub)
-func readNodePower() float64 {
-    // In production, this would query BMC via IPMI or Redfish
-    // For now, aggregate CPU + GPU + overhead
-    cpuPower, _ := readRAPL()
-    gpuPower := 0.0
-    
-    for i := 0; i < getGPUCount(); i++ {
-        p, _, _, _, _ := readNvidiaGPU(i)
-        gpuPower += p
-    }
-    
-    // Add 20% overhead for other components
+    // BMC unreachable (or reported nothing): aggregate CPU + GPU + overhead.
     return (cpuPower + gpuPower) * 1.2
 }
 
-func getGPUCount() int {
-    // Try to detect GPU count
-    cmd := exec.Command("nvidia-smi", "-L")
-    output, err := cmd.Output()
-    if err != nil {
-        return 2 // Mock 2 GPUs for development
+// runningOnJetson is decided once at startup: Jetson boards expose power
+// via INA3221 rails through tegrastats rather than RAPL/nvidia-smi, so the
+// x86 GPU backends must not be probed there.
+var runningOnJetson = isJetsonPlatform()
+
+func nodeName() string {
+    name := os.Getenv("NODE_NAME")
+    if name == "" {
+        name = "energy-node-1"
     }
-    return strings.Count(string(output), "GPU")
+    return name
 }
 
 func collectMetrics() {
-    nodeName := os.Getenv("NODE_NAME")
-    if nodeName == "" {
-        nodeName = "energy-node-1"
-    }
-    
+    nodeName := nodeName()
+    attributor := newPodAttributor(nodeName)
+    const interval = 10 * time.Second
+
     for {
-        // Collect CPU metrics
-        cpuPower, _ := readRAPL()
-        cpuPowerGauge.WithLabelValues(nodeName, "0").Set(cpuPower)
-        
-        // Collect GPU metrics
-        gpuCount := getGPUCount()
-        for i := 0; i < gpuCount; i++ {
-            power, temp, util, memory, _ := readNvidiaGPU(i)
-            gpuPowerGauge.WithLabelValues(nodeName, fmt.Sprintf("%d", i)).Set(power)
-            gpuTempGauge.WithLabelValues(nodeName, fmt.Sprintf("%d", i)).Set(temp)
-            gpuUtilGauge.WithLabelValues(nodeName, fmt.Sprintf("%d", i)).Set(util)
-            gpuMemoryGauge.WithLabelValues(nodeName, fmt.Sprintf("%d", i)).Set(memory)
+        // Collect CPU metrics: rapl.sample sets the per-domain gauges and
+        // energy counters itself and returns total package-level watts.
+        cpuPower := rapl.sample(nodeName)
+
+        // Collect GPU metrics from every registered vendor backend. Tegra
+        // boards are covered by the tegrastats streaming collector instead.
+        // nvidiaPowerByIndex records each NVIDIA GPU's own wattage, keyed by
+        // gpu_index: nvidia-smi pmon (which drives pod attribution) only
+        // ever reports NVIDIA process utilization, so that's the only
+        // vendor attribution can split per-GPU.
+        gpuPowerTotal := 0.0
+        nvidiaPowerByIndex := map[string]float64{}
+        if !runningOnJetson {
+            for _, collector := range gpuCollectors {
+                vendor := collector.Vendor()
+                for i := 0; i < collector.Count(); i++ {
+                    power, temp, util, memory, memBusyPct, memTotal, sclk, mclk, err := collector.Sample(i)
+                    if err != nil {
+                        log.Warnf("gpu collector %s: sampling index %d: %v", vendor, i, err)
+                        continue
+                    }
+                    gpuPowerTotal += power
+                    gpuIndex := fmt.Sprintf("%d", i)
+                    if vendor == "nvidia" {
+                        nvidiaPowerByIndex[gpuIndex] = power
+                    }
+                    gpuPowerGauge.WithLabelValues(nodeName, gpuIndex, vendor).Set(power)
+                    gpuTempGauge.WithLabelValues(nodeName, gpuIndex, vendor).Set(temp)
+                    gpuUtilGauge.WithLabelValues(nodeName, gpuIndex, vendor).Set(util)
+                    gpuMemoryGauge.WithLabelValues(nodeName, gpuIndex, vendor).Set(memory)
+                    gpuMemoryTotalGauge.WithLabelValues(nodeName, gpuIndex, vendor).Set(memTotal)
+                    gpuMemoryBusyGauge.WithLabelValues(nodeName, gpuIndex, vendor).Set(memBusyPct)
+                    gpuSclkGauge.WithLabelValues(nodeName, gpuIndex, vendor).Set(sclk)
+                    gpuMclkGauge.WithLabelValues(nodeName, gpuIndex, vendor).Set(mclk)
+                }
+            }
         }
-        
+
         // Collect total node power
-        nodePower := readNodePower()
+        nodePower := readNodePower(nodeName, cpuPower, gpuPowerTotal)
         nodePowerGauge.WithLabelValues(nodeName).Set(nodePower)
-        
-        time.Sleep(10 * time.Second)
+
+        // Split CPU/GPU power across the pods/containers running on this
+        // node, when running in-cluster.
+        attributor.attribute(cpuPower, nvidiaPowerByIndex, interval)
+
+        time.Sleep(interval)
     }
 }
 
 func main() {
+    flag.Parse()
+    initMetricRouter()
+
     log.Info("Starting Energy Agent Exporter...")
-    
+
+    if runningOnJetson {
+        log.Info("Jetson/Tegra platform detected, starting tegrastats collector")
+        startJetsonCollector(nodeName())
+    }
+
     // Start metrics collection
     go collectMetrics()
     
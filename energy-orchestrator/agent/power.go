@@ -0,0 +1,323 @@
+package main
+
+import (
+    "context"
+    "crypto/tls"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "net/http"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    log "github.com/sirupsen/logrus"
+)
+
+var (
+    redfishCredFile = flag.String("redfish-cred-file", os.Getenv("REDFISH_CRED_FILE"),
+        "path to a JSON file mapping node name to BMC credentials (env REDFISH_CRED_FILE)")
+
+    bmcPowerGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_bmc_power_watts",
+            Help: "Power consumption in watts as reported by the node's BMC (Redfish/IPMI)",
+        },
+        []string{"node", "psu"},
+    )
+
+    bmcInletTempGauge = prometheus.NewGaugeVec(
+        prometheus.GaugeOpts{
+            Name: "node_bmc_inlet_temp_celsius",
+            Help: "Chassis inlet temperature in Celsius as reported by the node's BMC",
+        },
+        []string{"node"},
+    )
+)
+
+func init() {
+    prometheus.MustRegister(bmcPowerGauge)
+    prometheus.MustRegister(bmcInletTempGauge)
+}
+
+// bmcCredential is one entry of the --redfish-cred-file JSON document,
+// keyed by node name.
+type bmcCredential struct {
+    BMCHost     string `json:"bmc_host"`
+    Username    string `json:"username"`
+    Password    string `json:"password"`
+    InsecureTLS bool   `json:"insecure_tls"`
+    // ChassisID is the Redfish Chassis member to poll for power (the "{id}"
+    // in /redfish/v1/Chassis/{id}/Power). Defaults to "1", which is what
+    // most single-chassis BMCs use, but multi-chassis/blade enclosures need
+    // this set explicitly per node.
+    ChassisID string `json:"chassis_id"`
+}
+
+// bmcReading is what either BMC backend produces for a single poll.
+type bmcReading struct {
+    PSUWatts    map[string]float64 // psu label -> watts
+    InletTempC  float64
+    HasInletTemp bool
+}
+
+// PowerSource is a backend capable of reading chassis power directly from
+// a node's management controller.
+type PowerSource interface {
+    Name() string
+    Read(node string, cred bmcCredential) (bmcReading, error)
+}
+
+const bmcBackoff = 60 * time.Second
+
+// bmcState tracks per-node credential caching and failure backoff so a
+// dead BMC doesn't get hammered every collection tick.
+type bmcState struct {
+    cred        bmcCredential
+    credLoaded  bool
+    backoffUntil time.Time
+}
+
+var (
+    bmcMu     sync.Mutex
+    bmcStates = map[string]*bmcState{}
+    bmcCreds  map[string]bmcCredential // loaded once from redfishCredFile
+    bmcCredsLoaded bool
+
+    redfishClient PowerSource = &RedfishClient{}
+    ipmiClient    PowerSource = &IPMIClient{}
+)
+
+// loadBMCCredentials parses --redfish-cred-file once and caches the result.
+func loadBMCCredentials() map[string]bmcCredential {
+    bmcMu.Lock()
+    defer bmcMu.Unlock()
+
+    if bmcCredsLoaded {
+        return bmcCreds
+    }
+    bmcCredsLoaded = true
+    bmcCreds = map[string]bmcCredential{}
+
+    path := *redfishCredFile
+    if path == "" {
+        return bmcCreds
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        log.Warnf("bmc: could not read credentials file %s: %v", path, err)
+        return bmcCreds
+    }
+
+    if err := json.Unmarshal(data, &bmcCreds); err != nil {
+        log.Warnf("bmc: could not parse credentials file %s: %v", path, err)
+        bmcCreds = map[string]bmcCredential{}
+    }
+    return bmcCreds
+}
+
+func stateFor(node string) *bmcState {
+    // loadBMCCredentials takes bmcMu itself, so it must run before we do.
+    creds := loadBMCCredentials()
+
+    bmcMu.Lock()
+    defer bmcMu.Unlock()
+
+    s, ok := bmcStates[node]
+    if !ok {
+        s = &bmcState{}
+        bmcStates[node] = s
+    }
+    if !s.credLoaded {
+        s.cred = creds[node]
+        s.credLoaded = true
+    }
+    return s
+}
+
+// readBMCPower attempts to read chassis power for node via Redfish first,
+// then IPMI. It returns ok=false if neither backend is reachable, in
+// which case the caller should fall back to the CPU+GPU+overhead estimate.
+func readBMCPower(node string) (reading bmcReading, ok bool) {
+    s := stateFor(node)
+
+    bmcMu.Lock()
+    backingOff := time.Now().Before(s.backoffUntil)
+    cred := s.cred
+    bmcMu.Unlock()
+
+    if backingOff {
+        return bmcReading{}, false
+    }
+
+    if cred.BMCHost != "" {
+        reading, err := redfishClient.Read(node, cred)
+        if err == nil {
+            return reading, true
+        }
+        log.Warnf("bmc: redfish read failed for %s: %v", node, err)
+    }
+
+    reading, err := ipmiClient.Read(node, cred)
+    if err == nil {
+        return reading, true
+    }
+    log.Warnf("bmc: ipmi read failed for %s: %v", node, err)
+
+    bmcMu.Lock()
+    s.backoffUntil = time.Now().Add(bmcBackoff)
+    bmcMu.Unlock()
+
+    return bmcReading{}, false
+}
+
+// RedfishClient reads chassis power over the Redfish Power schema.
+type RedfishClient struct{}
+
+func (c *RedfishClient) Name() string { return "redfish" }
+
+func (c *RedfishClient) Read(node string, cred bmcCredential) (bmcReading, error) {
+    if cred.BMCHost == "" {
+        return bmcReading{}, fmt.Errorf("no bmc_host configured for node %s", node)
+    }
+
+    httpClient := &http.Client{
+        Timeout: 5 * time.Second,
+        Transport: &http.Transport{
+            TLSClientConfig: &tls.Config{InsecureSkipVerify: cred.InsecureTLS},
+        },
+    }
+
+    chassisID := cred.ChassisID
+    if chassisID == "" {
+        chassisID = "1"
+    }
+    url := fmt.Sprintf("https://%s/redfish/v1/Chassis/%s/Power", cred.BMCHost, chassisID)
+    req, err := http.NewRequest(http.MethodGet, url, nil)
+    if err != nil {
+        return bmcReading{}, err
+    }
+    req.SetBasicAuth(cred.Username, cred.Password)
+
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        return bmcReading{}, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return bmcReading{}, fmt.Errorf("redfish %s: unexpected status %d", url, resp.StatusCode)
+    }
+
+    var power struct {
+        PowerControl []struct {
+            PowerConsumedWatts float64 `json:"PowerConsumedWatts"`
+        } `json:"PowerControl"`
+        PowerSupplies []struct {
+            Name            string  `json:"Name"`
+            MemberID        string  `json:"MemberId"`
+            PowerInputWatts float64 `json:"PowerInputWatts"`
+        } `json:"PowerSupplies"`
+        Temperatures []struct {
+            Name   string  `json:"Name"`
+            ReadingCelsius float64 `json:"ReadingCelsius"`
+        } `json:"Temperatures"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&power); err != nil {
+        return bmcReading{}, fmt.Errorf("decoding redfish power payload: %w", err)
+    }
+
+    reading := bmcReading{PSUWatts: map[string]float64{}}
+
+    for i, psu := range power.PowerSupplies {
+        label := psu.MemberID
+        if label == "" {
+            label = psu.Name
+        }
+        if label == "" {
+            label = fmt.Sprintf("psu%d", i)
+        }
+        reading.PSUWatts[label] = psu.PowerInputWatts
+    }
+
+    if len(reading.PSUWatts) == 0 && len(power.PowerControl) > 0 {
+        // Some BMCs only expose an aggregate PowerControl reading rather
+        // than per-PSU PowerSupplies entries.
+        reading.PSUWatts["total"] = power.PowerControl[0].PowerConsumedWatts
+    }
+
+    for _, t := range power.Temperatures {
+        if strings.Contains(strings.ToLower(t.Name), "inlet") {
+            reading.InletTempC = t.ReadingCelsius
+            reading.HasInletTemp = true
+            break
+        }
+    }
+
+    return reading, nil
+}
+
+// IPMIClient shells out to ipmitool as a fallback when Redfish isn't
+// available on a BMC.
+type IPMIClient struct{}
+
+func (c *IPMIClient) Name() string { return "ipmi" }
+
+// ipmitoolTimeout bounds how long a single ipmitool invocation may run.
+// Without it, a BMC that accepts the connection but never responds would
+// block this goroutine (and therefore the entire collectMetrics tick for
+// this node) indefinitely instead of tripping the backoff in readBMCPower.
+const ipmitoolTimeout = 5 * time.Second
+
+func (c *IPMIClient) Read(node string, cred bmcCredential) (bmcReading, error) {
+    args := []string{"dcmi", "power", "reading"}
+    if cred.BMCHost != "" {
+        args = append([]string{"-I", "lanplus", "-H", cred.BMCHost, "-U", cred.Username, "-P", cred.Password}, args...)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), ipmitoolTimeout)
+    defer cancel()
+
+    cmd := exec.CommandContext(ctx, "ipmitool", args...)
+    output, err := cmd.Output()
+    if ctx.Err() == context.DeadlineExceeded {
+        return bmcReading{}, fmt.Errorf("ipmitool: timed out after %s", ipmitoolTimeout)
+    }
+    if err != nil {
+        return bmcReading{}, fmt.Errorf("ipmitool: %w", err)
+    }
+
+    watts, err := parseIPMIInstantaneousWatts(string(output))
+    if err != nil {
+        return bmcReading{}, err
+    }
+
+    return bmcReading{PSUWatts: map[string]float64{"total": watts}}, nil
+}
+
+// parseIPMIInstantaneousWatts pulls the "Instantaneous power reading" line
+// out of `ipmitool dcmi power reading` output, e.g.:
+//
+//	Instantaneous power reading:                   342 Watts
+func parseIPMIInstantaneousWatts(output string) (float64, error) {
+    for _, line := range strings.Split(output, "\n") {
+        if !strings.Contains(line, "Instantaneous power reading") {
+            continue
+        }
+        parts := strings.Split(line, ":")
+        if len(parts) < 2 {
+            continue
+        }
+        fields := strings.Fields(parts[1])
+        if len(fields) == 0 {
+            continue
+        }
+        return strconv.ParseFloat(fields[0], 64)
+    }
+    return 0, fmt.Errorf("could not find instantaneous power reading in ipmitool output")
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestParseIPMIInstantaneousWatts(t *testing.T) {
+    const output = `Instantaneous power reading:                   342 Watts
+Minimum during sampling period:                 120 Watts
+Maximum during sampling period:                 410 Watts
+`
+    watts, err := parseIPMIInstantaneousWatts(output)
+    if err != nil {
+        t.Fatalf("parseIPMIInstantaneousWatts: %v", err)
+    }
+    if watts != 342 {
+        t.Errorf("watts = %v, want 342", watts)
+    }
+}
+
+func TestParseIPMIInstantaneousWattsMissing(t *testing.T) {
+    if _, err := parseIPMIInstantaneousWatts("no relevant lines here\n"); err == nil {
+        t.Error("expected an error when the instantaneous reading line is absent")
+    }
+}
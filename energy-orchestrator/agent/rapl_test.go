@@ -0,0 +1,150 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "strconv"
+    "testing"
+    "time"
+)
+
+func writeEnergyFile(t *testing.T, microjoules float64) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "energy_uj")
+    if err := os.WriteFile(path, []byte(strconv.FormatFloat(microjoules, 'f', 0, 64)), 0o644); err != nil {
+        t.Fatalf("writing fixture: %v", err)
+    }
+    return path
+}
+
+func TestEnergyDomainTickFirstReadHasNoDelta(t *testing.T) {
+    d := &energyDomain{Path: writeEnergyFile(t, 1000)}
+
+    watts, joules, err := d.tick()
+    if err != nil {
+        t.Fatalf("tick: %v", err)
+    }
+    if watts != 0 || joules != 0 {
+        t.Errorf("first tick = (%v, %v), want (0, 0) since there's no prior sample", watts, joules)
+    }
+    if !d.havePrev {
+        t.Error("expected havePrev to be set after the first tick")
+    }
+}
+
+func TestEnergyDomainTickComputesWattsFromDelta(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "energy_uj")
+    if err := os.WriteFile(path, []byte("1000000"), 0o644); err != nil {
+        t.Fatalf("writing fixture: %v", err)
+    }
+    d := &energyDomain{Path: path}
+
+    if _, _, err := d.tick(); err != nil {
+        t.Fatalf("first tick: %v", err)
+    }
+    d.prevTime = time.Now().Add(-1 * time.Second)
+
+    if err := os.WriteFile(path, []byte("2000000"), 0o644); err != nil {
+        t.Fatalf("updating fixture: %v", err)
+    }
+
+    watts, joules, err := d.tick()
+    if err != nil {
+        t.Fatalf("second tick: %v", err)
+    }
+    if joules != 1.0 {
+        t.Errorf("joules = %v, want 1.0 (1e6 uJ delta)", joules)
+    }
+    if watts < 0.9 || watts > 1.1 {
+        t.Errorf("watts = %v, want ~1.0 over a ~1s interval", watts)
+    }
+}
+
+func TestEnergyDomainTickHandlesWraparound(t *testing.T) {
+    const maxRange = 1000000.0 // 1 J range
+
+    path := filepath.Join(t.TempDir(), "energy_uj")
+    if err := os.WriteFile(path, []byte("900000"), 0o644); err != nil {
+        t.Fatalf("writing fixture: %v", err)
+    }
+    d := &energyDomain{Path: path, maxEnergyRangeUJ: maxRange}
+
+    if _, _, err := d.tick(); err != nil {
+        t.Fatalf("first tick: %v", err)
+    }
+    d.prevTime = time.Now().Add(-1 * time.Second)
+
+    // Counter wrapped past maxEnergyRangeUJ and is back at 100000: the real
+    // delta is (maxRange - 900000) + 100000 = 200000 uJ = 0.2 J, not the
+    // negative raw difference.
+    if err := os.WriteFile(path, []byte("100000"), 0o644); err != nil {
+        t.Fatalf("updating fixture: %v", err)
+    }
+
+    _, joules, err := d.tick()
+    if err != nil {
+        t.Fatalf("wraparound tick: %v", err)
+    }
+    if joules != 0.2 {
+        t.Errorf("joules after wraparound = %v, want 0.2", joules)
+    }
+}
+
+func TestEnergyDomainTickUnknownRangeDropsOnWraparound(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "energy_uj")
+    if err := os.WriteFile(path, []byte("900000"), 0o644); err != nil {
+        t.Fatalf("writing fixture: %v", err)
+    }
+    d := &energyDomain{Path: path} // maxEnergyRangeUJ left at 0: unknown range
+
+    if _, _, err := d.tick(); err != nil {
+        t.Fatalf("first tick: %v", err)
+    }
+
+    if err := os.WriteFile(path, []byte("100000"), 0o644); err != nil {
+        t.Fatalf("updating fixture: %v", err)
+    }
+
+    watts, joules, err := d.tick()
+    if err != nil {
+        t.Fatalf("tick: %v", err)
+    }
+    if watts != 0 || joules != 0 {
+        t.Errorf("tick with unknown range on wraparound = (%v, %v), want (0, 0)", watts, joules)
+    }
+}
+
+func TestSamplePerfOnlyFallbackFailureReturnsZero(t *testing.T) {
+    // Neither powercap sysfs nor perf_event is readable in this environment
+    // (no "perf" binary, let alone CAP_PERFMON), so this exercises the
+    // real "both backends unavailable" path: sample must report 0 rather
+    // than a fabricated wattage that would otherwise get multiplied into
+    // container_cpu_joules_total by podAttributor.attribute.
+    s := &raplSampler{discovered: true, perfEventOnly: true}
+
+    got := s.sample("rapl-test-no-perf-node")
+    if got != 0 {
+        t.Errorf("sample() with no powercap/perf access = %v, want 0", got)
+    }
+}
+
+func TestPackageDomainExcludesPsys(t *testing.T) {
+    cases := []struct {
+        dirName, name string
+        wantPackage   bool
+    }{
+        {"intel-rapl:0", "package-0", true},
+        {"intel-rapl:0", "psys", false},
+        {"intel-rapl:0:0", "dram", false},
+    }
+    for _, tc := range cases {
+        m := raplDomainRe.FindStringSubmatch(tc.dirName)
+        if m == nil {
+            t.Fatalf("raplDomainRe did not match %q", tc.dirName)
+        }
+        got := isRAPLPackageDomain(m[2], tc.name)
+        if got != tc.wantPackage {
+            t.Errorf("IsPackage(%q, name=%q) = %v, want %v", tc.dirName, tc.name, got, tc.wantPackage)
+        }
+    }
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    log "github.com/sirupsen/logrus"
+    "gopkg.in/yaml.v2"
+)
+
+var routerConfigFile = flag.String("router-config", os.Getenv("ROUTER_CONFIG"),
+    "path to a router.yaml describing metric rename/unit-conversion/tag rules (env ROUTER_CONFIG)")
+
+// metricRouter is nil unless --router-config is set, in which case
+// routeMetric below becomes a no-op-free path for collectors that want
+// their raw samples reshaped without a recompile.
+var metricRouter *MetricRouter
+
+// initMetricRouter loads --router-config, if given, and must be called
+// once from main() after flag.Parse().
+func initMetricRouter() {
+    if *routerConfigFile == "" {
+        return
+    }
+    cfg, err := LoadRouterConfig(*routerConfigFile)
+    if err != nil {
+        log.Warnf("router: %v; metric routing disabled", err)
+        return
+    }
+    metricRouter = NewMetricRouter(cfg)
+    log.Infof("router: loaded %d rule(s) from %s", len(cfg.Rules), *routerConfigFile)
+}
+
+// routeMetric publishes m through the router if one is configured; it is
+// a no-op otherwise so collectors can call it unconditionally.
+func routeMetric(m Metric) {
+    if metricRouter == nil {
+        return
+    }
+    metricRouter.Publish(m)
+}
+
+// Metric is a single raw sample produced by a collector before it has
+// been normalized to a canonical unit or registered as a Prometheus
+// series. This mirrors cc-metric-collector's internal representation so
+// backends that report in whatever unit is native to them (Jetson's mW,
+// RAPL's µJ, nvidia-smi's W) don't need to agree on units up front.
+type Metric struct {
+    Name      string
+    Tags      map[string]string
+    Fields    map[string]float64
+    Unit      string
+    Timestamp time.Time
+}
+
+// RouterRule describes one reshaping step applied to metrics whose name
+// matches Match (an exact name, or a "prefix*" glob).
+type RouterRule struct {
+    Match       string            `yaml:"match"`
+    Rename      string            `yaml:"rename,omitempty"`
+    ConvertUnit string            `yaml:"convert_unit,omitempty"`
+    AddTags     map[string]string `yaml:"add_tags,omitempty"`
+    DropTags    []string          `yaml:"drop_tags,omitempty"`
+    Drop        bool              `yaml:"drop,omitempty"`
+}
+
+// RouterConfig is the top-level shape of router.yaml.
+type RouterConfig struct {
+    Rules []RouterRule `yaml:"rules"`
+}
+
+// LoadRouterConfig reads and parses a router.yaml-style file.
+func LoadRouterConfig(path string) (*RouterConfig, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("reading router config %s: %w", path, err)
+    }
+
+    var cfg RouterConfig
+    if err := yaml.Unmarshal(data, &cfg); err != nil {
+        return nil, fmt.Errorf("parsing router config %s: %w", path, err)
+    }
+    return &cfg, nil
+}
+
+func (r RouterRule) matches(name string) bool {
+    if strings.HasSuffix(r.Match, "*") {
+        return strings.HasPrefix(name, strings.TrimSuffix(r.Match, "*"))
+    }
+    return r.Match == name
+}
+
+// MetricRouter applies a configured rule set to raw metrics and
+// publishes the result as dynamically-registered Prometheus gauges,
+// keyed by the final (possibly renamed) metric name and tag set.
+type MetricRouter struct {
+    rules []RouterRule
+
+    mu         sync.Mutex
+    gauges     map[string]*prometheus.GaugeVec // metric name -> its one registered GaugeVec
+    labelNames map[string][]string             // metric name -> the label set it was registered with
+}
+
+// NewMetricRouter builds a router from a parsed config. A nil config
+// is equivalent to an empty rule set (metrics pass through unchanged).
+func NewMetricRouter(cfg *RouterConfig) *MetricRouter {
+    router := &MetricRouter{
+        gauges:     map[string]*prometheus.GaugeVec{},
+        labelNames: map[string][]string{},
+    }
+    if cfg != nil {
+        router.rules = cfg.Rules
+    }
+    return router
+}
+
+// Run reads from in until it is closed, routing and publishing each
+// metric. Intended to be started with `go router.Run(ch)`.
+func (mr *MetricRouter) Run(in <-chan Metric) {
+    for m := range in {
+        mr.Publish(m)
+    }
+}
+
+// Publish applies matching rules to m and, unless dropped, sets the
+// corresponding Prometheus gauge(s), creating them on first use.
+func (mr *MetricRouter) Publish(m Metric) {
+    routed, ok := mr.route(m)
+    if !ok {
+        return
+    }
+
+    tagNames, tagValues := sortedTags(routed.Tags)
+
+    for field, value := range routed.Fields {
+        name := routed.Name
+        if field != "value" {
+            name = routed.Name + "_" + field
+        }
+        gauge, ok := mr.gaugeFor(name, tagNames)
+        if !ok {
+            continue
+        }
+        gauge.WithLabelValues(tagValues...).Set(value)
+    }
+}
+
+func (mr *MetricRouter) route(m Metric) (Metric, bool) {
+    for _, rule := range mr.rules {
+        if !rule.matches(m.Name) {
+            continue
+        }
+        if rule.Drop {
+            return Metric{}, false
+        }
+        if rule.Rename != "" {
+            m.Name = rule.Rename
+        }
+        if rule.ConvertUnit != "" && m.Unit != "" {
+            converted := make(map[string]float64, len(m.Fields))
+            for field, value := range m.Fields {
+                v, err := ConvertUnit(value, m.Unit, rule.ConvertUnit)
+                if err != nil {
+                    log.Warnf("router: %v", err)
+                    v = value
+                }
+                converted[field] = v
+            }
+            m.Fields = converted
+            m.Unit = rule.ConvertUnit
+        }
+        if len(rule.AddTags) > 0 {
+            tags := make(map[string]string, len(m.Tags)+len(rule.AddTags))
+            for k, v := range m.Tags {
+                tags[k] = v
+            }
+            for k, v := range rule.AddTags {
+                tags[k] = v
+            }
+            m.Tags = tags
+        }
+        for _, drop := range rule.DropTags {
+            delete(m.Tags, drop)
+        }
+    }
+    return m, true
+}
+
+// gaugeFor returns (creating if necessary) the GaugeVec registered under
+// name. Prometheus registers one descriptor per metric name, so every
+// sample published under that name must carry the same label set -
+// that's the whole point of rename/routing letting unrelated backends
+// with different native tags (Jetson's "rail", RAPL's "domain",
+// nvidia-smi's "gpu_index") converge on one name. A rule that tries to
+// route a different tag set into an already-registered name (including a
+// statically-registered gauge like node_cpu_power_watts) is rejected and
+// logged instead of panicking through prometheus.MustRegister.
+func (mr *MetricRouter) gaugeFor(name string, tagNames []string) (gauge *prometheus.GaugeVec, ok bool) {
+    mr.mu.Lock()
+    defer mr.mu.Unlock()
+
+    if g, exists := mr.gauges[name]; exists {
+        if !sameLabels(mr.labelNames[name], tagNames) {
+            log.Warnf("router: %s already registered with labels %v, dropping sample with labels %v",
+                name, mr.labelNames[name], tagNames)
+            return nil, false
+        }
+        return g, true
+    }
+
+    g := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+        Name: name,
+        Help: fmt.Sprintf("Router-published metric %s", name),
+    }, tagNames)
+    if err := prometheus.Register(g); err != nil {
+        log.Warnf("router: could not register gauge %s: %v", name, err)
+        return nil, false
+    }
+    mr.gauges[name] = g
+    mr.labelNames[name] = tagNames
+    return g, true
+}
+
+// sameLabels reports whether a and b hold the same label names in the
+// same order. Both come from sortedTags, so equal label sets compare
+// equal; unsorted inputs with the same names compare unequal.
+func sameLabels(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func sortedTags(tags map[string]string) (names []string, values []string) {
+    for k := range tags {
+        names = append(names, k)
+    }
+    // Stable ordering so repeated calls for the same tag set reuse the
+    // same GaugeVec instead of creating a new one per permutation.
+    for i := 0; i < len(names); i++ {
+        for j := i + 1; j < len(names); j++ {
+            if names[j] < names[i] {
+                names[i], names[j] = names[j], names[i]
+            }
+        }
+    }
+    for _, name := range names {
+        values = append(values, tags[name])
+    }
+    return names, values
+}
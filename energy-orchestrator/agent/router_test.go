@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+// TestRouterPublishConflictingLabelsDoesNotPanic exercises the scenario two
+// unrelated backends converging on one metric name under different label
+// sets (e.g. Jetson's "rail" vs RAPL's "domain" both renamed to a shared
+// name): the second publish must be rejected, not crash the agent via
+// prometheus.MustRegister.
+func TestRouterPublishConflictingLabelsDoesNotPanic(t *testing.T) {
+    mr := NewMetricRouter(nil)
+
+    mr.Publish(Metric{
+        Name:   "unified_power_watts",
+        Tags:   map[string]string{"node": "n1", "rail": "VDD_CPU"},
+        Fields: map[string]float64{"value": 1.2},
+    })
+
+    mr.Publish(Metric{
+        Name:   "unified_power_watts",
+        Tags:   map[string]string{"node": "n1", "domain": "package-0"},
+        Fields: map[string]float64{"value": 45.0},
+    })
+
+    if _, ok := mr.gauges["unified_power_watts"]; !ok {
+        t.Fatal("expected the first publish to register a gauge")
+    }
+}
+
+func TestRouterPublishSameLabelsReusesGauge(t *testing.T) {
+    mr := NewMetricRouter(nil)
+
+    for i := 0; i < 3; i++ {
+        mr.Publish(Metric{
+            Name:   "node_tegra_rail_power_watts_routed",
+            Tags:   map[string]string{"node": "n1", "rail": "VDD_CPU"},
+            Fields: map[string]float64{"value": float64(i)},
+        })
+    }
+
+    if len(mr.gauges) != 1 {
+        t.Fatalf("expected exactly one registered gauge, got %d", len(mr.gauges))
+    }
+}
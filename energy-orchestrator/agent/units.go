@@ -0,0 +1,81 @@
+package main
+
+import "fmt"
+
+// unitScale gives the multiplier to convert a value expressed in `unit`
+// into its canonical base unit (W, J, Hz, or bytes). SI prefixes are
+// decimal; "byte"-family units additionally recognize IEC binary
+// prefixes (Ki/Mi/Gi) since that's what most tooling actually emits.
+var unitScale = map[string]float64{
+    // power
+    "mW": 1e-3,
+    "W":  1,
+    "kW": 1e3,
+
+    // energy
+    "uJ": 1e-6,
+    "µJ": 1e-6,
+    "mJ": 1e-3,
+    "J":  1,
+    "kJ": 1e3,
+
+    // frequency
+    "Hz":  1,
+    "kHz": 1e3,
+    "MHz": 1e6,
+    "GHz": 1e9,
+
+    // data size (decimal)
+    "B":  1,
+    "KB": 1e3,
+    "MB": 1e6,
+    "GB": 1e9,
+    "TB": 1e12,
+
+    // data size (binary)
+    "KiB": 1024,
+    "MiB": 1024 * 1024,
+    "GiB": 1024 * 1024 * 1024,
+    "TiB": 1024 * 1024 * 1024 * 1024,
+}
+
+// unitBase maps each known unit to the canonical unit it converts into.
+var unitBase = map[string]string{
+    "mW": "W", "W": "W", "kW": "W",
+    "uJ": "J", "µJ": "J", "mJ": "J", "J": "J", "kJ": "J",
+    "Hz": "Hz", "kHz": "Hz", "MHz": "Hz", "GHz": "Hz",
+    "B": "bytes", "KB": "bytes", "MB": "bytes", "GB": "bytes", "TB": "bytes",
+    "KiB": "bytes", "MiB": "bytes", "GiB": "bytes", "TiB": "bytes",
+}
+
+// ConvertUnit converts value from `from` to `to`. Both units must share
+// the same base quantity (e.g. both power, or both data size) or an error
+// is returned. "bytes" is accepted as both a unit and a base name.
+func ConvertUnit(value float64, from, to string) (float64, error) {
+    if from == to {
+        return value, nil
+    }
+
+    fromScale, ok := unitScale[from]
+    if !ok {
+        if from == "bytes" {
+            fromScale, from = 1, "B"
+        } else {
+            return 0, fmt.Errorf("unknown unit %q", from)
+        }
+    }
+    toScale, ok := unitScale[to]
+    if !ok {
+        if to == "bytes" {
+            toScale, to = 1, "B"
+        } else {
+            return 0, fmt.Errorf("unknown unit %q", to)
+        }
+    }
+
+    if unitBase[from] != unitBase[to] {
+        return 0, fmt.Errorf("cannot convert %q to %q: different quantities", from, to)
+    }
+
+    return value * fromScale / toScale, nil
+}
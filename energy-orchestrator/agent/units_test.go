@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestConvertUnit(t *testing.T) {
+    cases := []struct {
+        value     float64
+        from, to  string
+        want      float64
+        wantError bool
+    }{
+        {value: 1500, from: "mW", to: "W", want: 1.5},
+        {value: 2, from: "kW", to: "W", want: 2000},
+        {value: 1, from: "uJ", to: "J", want: 1e-6},
+        {value: 1, from: "MHz", to: "Hz", want: 1e6},
+        {value: 1, from: "MiB", to: "bytes", want: 1024 * 1024},
+        {value: 5, from: "W", to: "W", want: 5},
+        {value: 1, from: "W", to: "J", wantError: true},
+        {value: 1, from: "bogus", to: "W", wantError: true},
+    }
+
+    for _, tc := range cases {
+        got, err := ConvertUnit(tc.value, tc.from, tc.to)
+        if tc.wantError {
+            if err == nil {
+                t.Errorf("ConvertUnit(%v, %q, %q) = %v, want error", tc.value, tc.from, tc.to, got)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("ConvertUnit(%v, %q, %q) unexpected error: %v", tc.value, tc.from, tc.to, err)
+            continue
+        }
+        if got != tc.want {
+            t.Errorf("ConvertUnit(%v, %q, %q) = %v, want %v", tc.value, tc.from, tc.to, got, tc.want)
+        }
+    }
+}